@@ -1,7 +1,9 @@
 package main
 
 import (
+	"compress/gzip"
 	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -11,17 +13,51 @@ import (
 	"sort"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
 
+	"github.com/gobwas/glob"
+	"github.com/jack-scott/misc_snippets/file_monitor/fs"
+	"github.com/jack-scott/misc_snippets/file_monitor/ignore"
+	"github.com/jack-scott/misc_snippets/file_monitor/store"
 	"github.com/vmihailenco/msgpack/v5"
 )
 
+// monitorIgnoreFile is the name of the gitignore-style file readIgnoreLines
+// reads, both at the scan root and in any subdirectory.
+const monitorIgnoreFile = ".monitorignore"
+
+// blockSize is the fixed block size used for content hashing, matching the
+// chunk size restic/syncthing use for their non-rolling fast path.
+const blockSize = 128 * 1024
+
+// maxConcurrentBlockReads bounds how many files can be read into memory for
+// block hashing at once, independent of the stat worker pool size.
+const maxConcurrentBlockReads = 4
+
+// Raw stat mode bits, kept as local constants (rather than importing
+// syscall) so CalculateSummaryHash's device-file check works on every
+// platform a Filesystem implementation might target.
+const (
+	modeFmt = 0170000
+	modeBlk = 0060000
+	modeChr = 0020000
+)
+
+// Block describes one fixed-size chunk of a file's content.
+type Block struct {
+	Offset int64    `json:"offset" msgpack:"offset"`
+	Size   int64    `json:"size" msgpack:"size"`
+	Weak   uint32   `json:"weak" msgpack:"weak"`
+	Strong [32]byte `json:"strong" msgpack:"strong"`
+}
+
 // FileInfo stores metadata for a single file
 type FileInfo struct {
-	Mtime float64 `json:"mtime" msgpack:"mtime"`
-	Mode  uint32  `json:"mode" msgpack:"mode"`
-	Rdev  uint64  `json:"rdev" msgpack:"rdev"`
+	Mtime  float64 `json:"mtime" msgpack:"mtime"`
+	Mode   uint32  `json:"mode" msgpack:"mode"`
+	Rdev   uint64  `json:"rdev" msgpack:"rdev"`
+	Hash   string  `json:"hash,omitempty" msgpack:"hash,omitempty"`
+	Blocks []Block `json:"blocks,omitempty" msgpack:"blocks,omitempty"`
 }
 
 // State represents the saved state
@@ -38,11 +74,19 @@ type FileMonitor struct {
 	rootPath        string
 	excludePatterns []string
 	files           map[string]FileInfo
-	useMsgpack      bool
+	backend         string // "msgpack", "json", or "sqlite"
+	hashMode        bool
+	blockSem        chan struct{}
+	filesystem      fs.Filesystem
+	stateFS         fs.Filesystem
+	watchMatchers   map[string]*ignore.Matcher // populated lazily by matcherForWatchDir
 }
 
-// NewFileMonitor creates a new file monitor
-func NewFileMonitor(rootPath string, excludePatterns []string) *FileMonitor {
+// NewFileMonitor creates a new file monitor that scans filesystem. State
+// (the saved scan used for comparison) always lives on the real disk via
+// stateFS, regardless of what filesystem is being scanned, since a
+// read-only backend like TarFS has nowhere to persist it.
+func NewFileMonitor(rootPath string, excludePatterns []string, hashMode bool, filesystem fs.Filesystem, backend string) *FileMonitor {
 	absPath, err := filepath.Abs(rootPath)
 	if err != nil {
 		absPath = rootPath
@@ -52,18 +96,203 @@ func NewFileMonitor(rootPath string, excludePatterns []string) *FileMonitor {
 		rootPath:        absPath,
 		excludePatterns: excludePatterns,
 		files:           make(map[string]FileInfo),
-		useMsgpack:      true,
+		backend:         backend,
+		hashMode:        hashMode,
+		blockSem:        make(chan struct{}, maxConcurrentBlockReads),
+		filesystem:      filesystem,
+		stateFS:         fs.NewBasicFS(),
+	}
+}
+
+// weakChecksum computes an adler32-style rolling weak hash over a block.
+// Since blocks here are fixed-size and non-overlapping, this is computed
+// fresh per block rather than rolled byte-by-byte; true rolling only
+// matters if variable-size chunking is added later.
+func weakChecksum(b []byte) uint32 {
+	const mod = 65521
+
+	var a, bSum uint32 = 1, 0
+	n := uint32(len(b))
+	for i, v := range b {
+		a = (a + uint32(v)) % mod
+		bSum = (bSum + (n-uint32(i))*uint32(v)) % mod
+	}
+
+	return bSum<<16 | a
+}
+
+// computeBlocks reads the file at path in blockSize chunks, returning the
+// per-block weak/strong hash index along with the whole-file SHA-256 hash
+// used for rename detection.
+func (fm *FileMonitor) computeBlocks(path string) ([]Block, string, error) {
+	fm.blockSem <- struct{}{}
+	defer func() { <-fm.blockSem }()
+
+	f, err := fm.filesystem.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	var blocks []Block
+	fileHasher := sha256.New()
+	buf := make([]byte, blockSize)
+	var offset int64
+
+	for {
+		n, err := io.ReadFull(f, buf)
+		if n > 0 {
+			chunk := buf[:n]
+			fileHasher.Write(chunk)
+
+			strongHasher := sha256.Sum256(chunk)
+			blocks = append(blocks, Block{
+				Offset: offset,
+				Size:   int64(n),
+				Weak:   weakChecksum(chunk),
+				Strong: strongHasher,
+			})
+			offset += int64(n)
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	return blocks, fmt.Sprintf("%x", fileHasher.Sum(nil)), nil
+}
+
+// gitignoreGlob translates one gitignore-style pattern line into the glob
+// syntax the ignore package compiles: a leading "/" anchors the pattern to
+// the directory the ignore file lives in, while an unanchored pattern
+// (the common case: ".git", "node_modules", ...) matches at any depth
+// below it - including directly under the ignore file's own directory.
+// gobwas/glob's "**" doesn't absorb an adjacent literal "/" when nothing
+// precedes it, so a plain "**/" prefix would never match a bare relative
+// path like ".git"; "{,**/}" is the alternation the library's own docs
+// recommend for "here, or anywhere below here".
+func gitignoreGlob(line string) string {
+	if strings.HasPrefix(line, "/") {
+		return strings.TrimPrefix(line, "/")
+	}
+	return "{,**/}" + line
+}
+
+// readIgnoreLines loads and translates the .monitorignore file in dir, if
+// one exists. A missing file is not an error - most directories won't
+// have one.
+func (fm *FileMonitor) readIgnoreLines(dir string) ([]string, error) {
+	f, err := fm.filesystem.Open(filepath.Join(dir, monitorIgnoreFile))
+	if err != nil {
+		return nil, nil
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
 	}
+
+	// Matchers are applied to paths relative to fm.rootPath, so a nested
+	// ignore file's patterns are prefixed with its own directory.
+	prefix := ""
+	if relDir, relErr := filepath.Rel(fm.rootPath, dir); relErr == nil && relDir != "." {
+		prefix = glob.QuoteMeta(filepath.ToSlash(relDir)) + "/"
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// "(?i)" / "(?d)" markers stay in front, where ignore.Compile
+		// looks for them.
+		markers := ""
+		for strings.HasPrefix(line, "(?i)") || strings.HasPrefix(line, "(?d)") {
+			markers += line[:4]
+			line = line[4:]
+		}
+
+		negate := strings.HasPrefix(line, "!")
+		if negate {
+			line = line[1:]
+		}
+
+		translated := prefix + gitignoreGlob(line)
+		if negate {
+			translated = "!" + translated
+		}
+		lines = append(lines, markers+translated)
+	}
+
+	return lines, nil
+}
+
+// rootMatcher builds the base ignore.Matcher for a scan: the legacy
+// --exclude / hardcoded excludePatterns (already gitignore-style, e.g.
+// "/proc" or "node_modules") plus the .monitorignore at the scan root.
+func (fm *FileMonitor) rootMatcher() (*ignore.Matcher, error) {
+	lines := make([]string, 0, len(fm.excludePatterns))
+	for _, p := range fm.excludePatterns {
+		lines = append(lines, gitignoreGlob(p))
+	}
+
+	rootLines, err := fm.readIgnoreLines(fm.rootPath)
+	if err != nil {
+		return nil, err
+	}
+	lines = append(lines, rootLines...)
+
+	return ignore.Compile(lines)
 }
 
-// shouldExclude checks if a path should be excluded
-func (fm *FileMonitor) shouldExclude(path string) bool {
-	for _, pattern := range fm.excludePatterns {
-		if strings.Contains(path, pattern) {
-			return true
+// ignoreScope is one entry in the directory-nested matcher stack
+// CollectFiles walks alongside the filesystem, so a deeper .monitorignore
+// only affects paths under the directory it was found in.
+type ignoreScope struct {
+	dir     string
+	matcher *ignore.Matcher
+}
+
+// matcherForDir returns the matcher in effect for dir, popping scopes for
+// directories CollectFiles has walked out of and pushing a new one if dir
+// has its own .monitorignore.
+func (fm *FileMonitor) matcherForDir(stack []ignoreScope, dir string) ([]ignoreScope, *ignore.Matcher, error) {
+	for len(stack) > 1 && !isWithinDir(stack[len(stack)-1].dir, dir) {
+		stack = stack[:len(stack)-1]
+	}
+
+	top := stack[len(stack)-1]
+	if top.dir == dir {
+		return stack, top.matcher, nil
+	}
+
+	lines, err := fm.readIgnoreLines(dir)
+	if err != nil {
+		return stack, top.matcher, err
+	}
+
+	matcher := top.matcher
+	if len(lines) > 0 {
+		matcher, err = ignore.Merge(top.matcher, lines)
+		if err != nil {
+			return stack, top.matcher, err
 		}
 	}
-	return false
+
+	stack = append(stack, ignoreScope{dir: dir, matcher: matcher})
+	return stack, matcher, nil
+}
+
+// isWithinDir reports whether dir is parent itself or lies beneath it.
+func isWithinDir(parent, dir string) bool {
+	return dir == parent || strings.HasPrefix(dir, strings.TrimSuffix(parent, string(filepath.Separator))+string(filepath.Separator))
 }
 
 // CollectFiles scans the filesystem and collects file metadata
@@ -88,26 +317,31 @@ func (fm *FileMonitor) CollectFiles() error {
 		go func() {
 			defer wg.Done()
 			for job := range jobs {
-				info, err := os.Lstat(job.path)
+				stat, err := fm.filesystem.Lstat(job.path)
 				if err != nil {
 					continue
 				}
 
-				stat, ok := info.Sys().(*syscall.Stat_t)
-				if !ok {
-					continue
+				fileInfo := FileInfo{
+					Mtime: stat.Mtime,
+					Mode:  stat.Mode,
+					Rdev:  stat.Rdev,
+				}
+
+				if fm.hashMode && !stat.IsDir {
+					blocks, hash, err := fm.computeBlocks(job.path)
+					if err == nil {
+						fileInfo.Blocks = blocks
+						fileInfo.Hash = hash
+					}
 				}
 
 				results <- struct {
 					rel  string
 					info FileInfo
 				}{
-					rel: job.rel,
-					info: FileInfo{
-						Mtime: float64(stat.Mtim.Sec) + float64(stat.Mtim.Nsec)/1e9,
-						Mode:  stat.Mode,
-						Rdev:  stat.Rdev,
-					},
+					rel:  job.rel,
+					info: fileInfo,
 				}
 			}
 		}()
@@ -121,23 +355,44 @@ func (fm *FileMonitor) CollectFiles() error {
 
 	// Walk filesystem and send jobs
 	go func() {
-		filepath.Walk(fm.rootPath, func(path string, info os.FileInfo, err error) error {
+		root, err := fm.rootMatcher()
+		if err != nil {
+			close(jobs)
+			return
+		}
+		stack := []ignoreScope{{dir: fm.rootPath, matcher: root}}
+
+		fm.filesystem.Walk(fm.rootPath, func(path string, info fs.FileInfo, err error) error {
 			if err != nil {
 				return nil
 			}
 
-			if fm.shouldExclude(path) {
-				if info.IsDir() {
-					return filepath.SkipDir
-				}
+			dir := path
+			if !info.IsDir {
+				dir = filepath.Dir(path)
+			}
+
+			var matcher *ignore.Matcher
+			stack, matcher, err = fm.matcherForDir(stack, dir)
+			if err != nil {
+				return nil
+			}
+
+			rel, relErr := filepath.Rel(fm.rootPath, path)
+			if relErr != nil {
 				return nil
 			}
 
-			if !info.IsDir() {
-				rel, err := filepath.Rel(fm.rootPath, path)
-				if err == nil {
-					jobs <- fileJob{path: path, rel: rel}
+			excluded, _ := matcher.Match(rel)
+			if excluded {
+				if info.IsDir && !matcher.CouldReinclude() {
+					return fs.SkipDir
 				}
+				return nil
+			}
+
+			if !info.IsDir {
+				jobs <- fileJob{path: path, rel: rel}
 			}
 
 			return nil
@@ -168,8 +423,8 @@ func (fm *FileMonitor) CalculateSummaryHash() string {
 
 		// For device files, use rdev; for regular files, use mtime
 		var data string
-		if (info.Mode & syscall.S_IFMT) == syscall.S_IFBLK ||
-			(info.Mode & syscall.S_IFMT) == syscall.S_IFCHR {
+		if (info.Mode&modeFmt) == modeBlk ||
+			(info.Mode&modeFmt) == modeChr {
 			major := (info.Rdev >> 8) & 0xff
 			minor := info.Rdev & 0xff
 			data = fmt.Sprintf("%s:dev:%d:%d", path, major, minor)
@@ -191,7 +446,7 @@ func (fm *FileMonitor) GetStateFilePath() string {
 	}
 
 	configDir := filepath.Join(homeDir, ".config", "file_monitor_go")
-	os.MkdirAll(configDir, 0755)
+	fm.stateFS.MkdirAll(configDir, 0755)
 
 	// Create unique filename based on path + excludes
 	pathStr := fm.rootPath + strings.Join(fm.excludePatterns, "")
@@ -207,8 +462,11 @@ func (fm *FileMonitor) GetStateFilePath() string {
 	}
 
 	ext := ".msgpack"
-	if !fm.useMsgpack {
+	switch fm.backend {
+	case "json":
 		ext = ".json"
+	case "sqlite":
+		ext = ".sqlite"
 	}
 
 	return filepath.Join(configDir, pathHash+"_"+safePath+ext)
@@ -216,6 +474,10 @@ func (fm *FileMonitor) GetStateFilePath() string {
 
 // SaveState saves the current state to a file
 func (fm *FileMonitor) SaveState(stateFile string) error {
+	if fm.backend == "sqlite" {
+		return fm.saveStateSQLite(stateFile)
+	}
+
 	state := State{
 		RootPath:    fm.rootPath,
 		Timestamp:   time.Now().Format(time.RFC3339),
@@ -227,22 +489,71 @@ func (fm *FileMonitor) SaveState(stateFile string) error {
 	var data []byte
 	var err error
 
-	if fm.useMsgpack {
-		data, err = msgpack.Marshal(&state)
-	} else {
+	if fm.backend == "json" {
 		data, err = json.Marshal(&state)
+	} else {
+		data, err = msgpack.Marshal(&state)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	out, err := fm.stateFS.Create(stateFile)
+	if err != nil {
+		return err
 	}
+	defer out.Close()
+
+	_, err = out.Write(data)
+	return err
+}
 
+// saveStateSQLite persists the current scan via the SQLite backend,
+// batching per-file upserts in transactions instead of rewriting one
+// giant blob on every run.
+func (fm *FileMonitor) saveStateSQLite(stateFile string) error {
+	db, err := store.Open(stateFile)
 	if err != nil {
 		return err
 	}
+	defer db.Close()
+
+	records := make([]store.Record, 0, len(fm.files))
+	for path, info := range fm.files {
+		hashBytes, _ := hex.DecodeString(info.Hash)
+		records = append(records, store.Record{
+			Path:  path,
+			Mtime: info.Mtime,
+			Mode:  info.Mode,
+			Rdev:  info.Rdev,
+			Hash:  hashBytes,
+		})
+	}
+
+	meta := store.RunMeta{
+		RootPath:    fm.rootPath,
+		Timestamp:   time.Now().Format(time.RFC3339),
+		FileCount:   len(records),
+		SummaryHash: fm.CalculateSummaryHash(),
+	}
 
-	return os.WriteFile(stateFile, data, 0644)
+	return db.SaveState(meta, records)
 }
 
 // LoadState loads a previous state from a file
 func (fm *FileMonitor) LoadState(stateFile string) (*State, error) {
-	data, err := os.ReadFile(stateFile)
+	if fm.backend == "sqlite" {
+		return fm.loadStateSQLite(stateFile)
+	}
+
+	in, err := fm.stateFS.Open(stateFile)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	data, err := io.ReadAll(in)
 	if err != nil {
 		return nil, err
 	}
@@ -262,8 +573,139 @@ func (fm *FileMonitor) LoadState(stateFile string) (*State, error) {
 	return &state, nil
 }
 
-// CompareStates compares current state with previous state
-func (fm *FileMonitor) CompareStates(previous *State) map[string][]string {
+// loadStateSQLite loads the most recent run's metadata from the SQLite
+// backend, without reading a single file row - CompareStates diffs
+// against the database directly instead of a Go map for this backend, so
+// there's nothing to populate State.Files with here.
+func (fm *FileMonitor) loadStateSQLite(stateFile string) (*State, error) {
+	db, err := store.Open(stateFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	meta, err := db.LoadMeta()
+	if err != nil {
+		return nil, err
+	}
+	if meta.RootPath == "" {
+		return nil, fmt.Errorf("no previous state found")
+	}
+
+	return &State{
+		RootPath:    meta.RootPath,
+		Timestamp:   meta.Timestamp,
+		FileCount:   meta.FileCount,
+		SummaryHash: meta.SummaryHash,
+	}, nil
+}
+
+// ChangedRange describes a byte range within a modified file, identified by
+// comparing block strong hashes between the previous and current scan.
+type ChangedRange struct {
+	Offset int64 `json:"offset"`
+	Size   int64 `json:"size"`
+}
+
+// Rename pairs a removed path with the added path it was detected to have
+// become, based on matching whole-file strong hashes.
+type Rename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Comparison is the result of diffing two scans of the same tree.
+type Comparison struct {
+	Added         []string                  `json:"added"`
+	Removed       []string                  `json:"removed"`
+	Modified      []string                  `json:"modified"`
+	Renamed       []Rename                  `json:"renamed"`
+	ChangedRanges map[string][]ChangedRange `json:"changed_ranges"`
+}
+
+// changedRanges returns the byte ranges whose block strong hash differs
+// between prev and curr. It falls back to reporting nothing (not "whole
+// file changed") when either side lacks a block index, since the caller
+// already knows the file is modified from the mtime/rdev comparison.
+func changedRanges(prev, curr FileInfo) []ChangedRange {
+	if len(prev.Blocks) == 0 || len(curr.Blocks) == 0 {
+		return nil
+	}
+
+	prevByOffset := make(map[int64]Block, len(prev.Blocks))
+	for _, b := range prev.Blocks {
+		prevByOffset[b.Offset] = b
+	}
+
+	var ranges []ChangedRange
+	for _, b := range curr.Blocks {
+		old, ok := prevByOffset[b.Offset]
+		if !ok || old.Strong != b.Strong || old.Size != b.Size {
+			ranges = append(ranges, ChangedRange{Offset: b.Offset, Size: b.Size})
+		}
+	}
+
+	return ranges
+}
+
+// matchRenames pairs added paths to removed paths by whole-file strong
+// hash. removedHashes maps a removed path to its hash (possibly "" if
+// unknown); hashOf looks up the hash of a currently-added path. Candidates
+// are tracked per hash and popped as they're consumed, so when several
+// removed files share a hash (duplicate content, multiple empty files)
+// each one satisfies at most one rename match instead of every added file
+// with that hash pairing to the same stale removed path.
+func matchRenames(removedHashes map[string]string, added, removed []string, hashOf func(path string) string) (renamed []Rename, remainingAdded, remainingRemoved []string) {
+	candidates := make(map[string][]string, len(removedHashes))
+	for path, h := range removedHashes {
+		if h == "" {
+			continue
+		}
+		candidates[h] = append(candidates[h], path)
+	}
+
+	consumed := make(map[string]bool, len(removed))
+	remainingAdded = added[:0:0]
+	for _, path := range added {
+		h := hashOf(path)
+		if h != "" && len(candidates[h]) > 0 {
+			from := candidates[h][0]
+			candidates[h] = candidates[h][1:]
+			consumed[from] = true
+			renamed = append(renamed, Rename{From: from, To: path})
+			continue
+		}
+		remainingAdded = append(remainingAdded, path)
+	}
+
+	remainingRemoved = removed[:0:0]
+	for _, path := range removed {
+		if !consumed[path] {
+			remainingRemoved = append(remainingRemoved, path)
+		}
+	}
+
+	return renamed, remainingAdded, remainingRemoved
+}
+
+// CompareStates compares current state with previous state. For the
+// sqlite backend, when previous came from loadStateSQLite (so
+// previous.Files is nil), the comparison is delegated to the database via
+// Diff instead of diffing two Go maps, so a rescan doesn't have to hold
+// every previously-seen file in memory. previous.Files is non-nil when a
+// caller (runPollWatch) builds it from an in-memory snapshot rather than
+// loading it from disk; that always takes the Go-map path regardless of
+// backend, since there's nothing checkpointed yet to diff against in SQL.
+func (fm *FileMonitor) CompareStates(stateFile string, previous *State) Comparison {
+	if fm.backend == "sqlite" && previous.Files == nil {
+		cmp, err := fm.compareStatesSQLite(stateFile)
+		if err == nil {
+			return cmp
+		}
+		fmt.Fprintf(os.Stderr, "Warning: sqlite diff failed (%v), reporting no changes\n", err)
+		return Comparison{}
+	}
+
 	currentFiles := make(map[string]bool)
 	for k := range fm.files {
 		currentFiles[k] = true
@@ -277,6 +719,7 @@ func (fm *FileMonitor) CompareStates(previous *State) map[string][]string {
 	added := []string{}
 	removed := []string{}
 	modified := []string{}
+	changedRangesByPath := make(map[string][]ChangedRange)
 
 	// Find added and modified
 	for path := range currentFiles {
@@ -287,6 +730,9 @@ func (fm *FileMonitor) CompareStates(previous *State) map[string][]string {
 			prev := previous.Files[path]
 			if curr.Mtime != prev.Mtime || curr.Rdev != prev.Rdev {
 				modified = append(modified, path)
+				if ranges := changedRanges(prev, curr); len(ranges) > 0 {
+					changedRangesByPath[path] = ranges
+				}
 			}
 		}
 	}
@@ -298,15 +744,86 @@ func (fm *FileMonitor) CompareStates(previous *State) map[string][]string {
 		}
 	}
 
+	// Detect renames by matching the whole-file strong hash of an added
+	// file against a removed file. Only possible in --hash mode, since
+	// that's the only time FileInfo.Hash is populated.
+	var renamed []Rename
+	if fm.hashMode {
+		removedHashes := make(map[string]string, len(removed))
+		for _, path := range removed {
+			removedHashes[path] = previous.Files[path].Hash
+		}
+
+		renamed, added, removed = matchRenames(removedHashes, added, removed, func(path string) string {
+			return fm.files[path].Hash
+		})
+	}
+
 	sort.Strings(added)
 	sort.Strings(removed)
 	sort.Strings(modified)
+	sort.Slice(renamed, func(i, j int) bool { return renamed[i].From < renamed[j].From })
+
+	return Comparison{
+		Added:         added,
+		Removed:       removed,
+		Modified:      modified,
+		Renamed:       renamed,
+		ChangedRanges: changedRangesByPath,
+	}
+}
+
+// compareStatesSQLite diffs the current scan against the sqlite backend
+// entirely in SQL (via store.Diff), so the comparison doesn't require
+// loading the previous run's files table into a Go map first. Rename
+// detection still happens in Go, but only fetches the hashes of the
+// handful of paths Diff reported removed.
+func (fm *FileMonitor) compareStatesSQLite(stateFile string) (Comparison, error) {
+	db, err := store.Open(stateFile)
+	if err != nil {
+		return Comparison{}, err
+	}
+	defer db.Close()
+
+	records := make([]store.Record, 0, len(fm.files))
+	for path, info := range fm.files {
+		hashBytes, _ := hex.DecodeString(info.Hash)
+		records = append(records, store.Record{
+			Path:  path,
+			Mtime: info.Mtime,
+			Mode:  info.Mode,
+			Rdev:  info.Rdev,
+			Hash:  hashBytes,
+		})
+	}
 
-	return map[string][]string{
-		"added":    added,
-		"removed":  removed,
-		"modified": modified,
+	added, removed, modified, err := db.Diff(records)
+	if err != nil {
+		return Comparison{}, err
+	}
+
+	var renamed []Rename
+	if fm.hashMode && len(removed) > 0 {
+		removedHashes, err := db.HashesFor(removed)
+		if err != nil {
+			return Comparison{}, err
+		}
+		renamed, added, removed = matchRenames(removedHashes, added, removed, func(path string) string {
+			return fm.files[path].Hash
+		})
 	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(modified)
+	sort.Slice(renamed, func(i, j int) bool { return renamed[i].From < renamed[j].From })
+
+	return Comparison{
+		Added:    added,
+		Removed:  removed,
+		Modified: modified,
+		Renamed:  renamed,
+	}, nil
 }
 
 // GroupByDirectory groups files by directory prefix
@@ -386,11 +903,92 @@ func formatGroupedChanges(files []string, symbol string, maxDisplay int, expandA
 	return output
 }
 
+// backendPriority is the order newMonitorWithBackend probes in when no
+// --backend flag was given and it has to guess which one a previous run
+// used.
+var backendPriority = []string{"sqlite", "msgpack", "json"}
+
+// newMonitorWithBackend builds a FileMonitor for the requested backend. If
+// requestedBackend is empty, it auto-detects by checking which backend's
+// state file already exists for this root path + excludes combination,
+// falling back to msgpack for a first-ever run.
+func newMonitorWithBackend(path string, excludePatterns []string, hashMode bool, filesystem fs.Filesystem, requestedBackend string) (*FileMonitor, string) {
+	if requestedBackend != "" {
+		monitor := NewFileMonitor(path, excludePatterns, hashMode, filesystem, requestedBackend)
+		return monitor, monitor.GetStateFilePath()
+	}
+
+	for _, candidate := range backendPriority {
+		monitor := NewFileMonitor(path, excludePatterns, hashMode, filesystem, candidate)
+		stateFile := monitor.GetStateFilePath()
+		if _, err := monitor.stateFS.Stat(stateFile); err == nil {
+			return monitor, stateFile
+		}
+	}
+
+	monitor := NewFileMonitor(path, excludePatterns, hashMode, filesystem, "msgpack")
+	return monitor, monitor.GetStateFilePath()
+}
+
+// openArchiveFS opens path as a read-only Filesystem based on its
+// extension, so --archive can scan a release tarball or zip without
+// extracting it first.
+func openArchiveFS(path string) (fs.Filesystem, error) {
+	switch {
+	case strings.HasSuffix(path, ".zip"):
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		// Not closed: ZipFS reads entries lazily from f for the life of
+		// the scan.
+		return fs.NewZipFS(f, info.Size())
+
+	case strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz"):
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		return fs.NewTarFS(gz)
+
+	case strings.HasSuffix(path, ".tar"):
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return fs.NewTarFS(f)
+
+	default:
+		return nil, fmt.Errorf("unrecognized archive extension (want .tar, .tar.gz, .tgz, or .zip): %s", path)
+	}
+}
+
 func main() {
 	// Command line flags (must come before positional arguments)
 	verbosePtr := flag.Bool("v", false, "Show all changed files")
 	allPtr := flag.Bool("all", false, "Include noisy directories when scanning root")
 	timingPtr := flag.Bool("timing", false, "Run timing benchmark")
+	hashPtr := flag.Bool("hash", false, "Compute per-file content hash and block index instead of relying on mtime alone")
+	backendPtr := flag.String("backend", "", "State backend: sqlite, msgpack, or json (default: auto-detect, falling back to msgpack)")
+	archivePtr := flag.String("archive", "", "Scan a .tar, .tar.gz/.tgz, or .zip archive instead of the real filesystem, without extracting it")
+	formatPtr := flag.String("format", "", "Output format: text, json, or ndjson (default: text, or ndjson with --no-console)")
+	noConsolePtr := flag.Bool("no-console", false, "Shorthand for --format=ndjson, for piping into other tools")
+
+	var watchFlag watchModeFlag
+	flag.Var(&watchFlag, "watch", "Keep running and report changes live: fsnotify (default) or poll for filesystems without inotify support")
+	watchDebouncePtr := flag.Duration("watch-debounce", 500*time.Millisecond, "Coalescing window for --watch; also used as the poll interval for --watch=poll")
+	watchCheckpointPtr := flag.Duration("watch-checkpoint", 30*time.Second, "How often --watch checkpoints state to disk")
 
 	var excludes []string
 	flag.Func("exclude", "Additional patterns to exclude (repeatable)", func(s string) error {
@@ -409,6 +1007,8 @@ func main() {
 	verbose := *verbosePtr
 	all := *allPtr
 	timing := *timingPtr
+	hashMode := *hashPtr
+	format := resolveFormat(*formatPtr, *noConsolePtr)
 
 	// Build exclude patterns
 	excludePatterns := []string{".git", "__pycache__", ".cache", "node_modules"}
@@ -425,18 +1025,46 @@ func main() {
 
 	// Timing benchmark
 	if timing {
-		runTimingBenchmark(absPath, excludePatterns)
+		runTimingBenchmark(absPath, excludePatterns, hashMode)
 		return
 	}
 
-	// Normal operation
-	fmt.Printf("Scanning: %s\n", absPath)
-	fmt.Printf("Excluding: %s\n\n", strings.Join(excludePatterns, ", "))
+	progress := newProgressPrinter(format)
+
+	// scanPath/displayPath diverge for --archive: the monitor's rootPath
+	// (and thus the Walk root it hands to the Filesystem) must be "/" to
+	// match TarFS/ZipFS's internal archive-relative namespace, while
+	// displayPath keeps the archive's real path in progress output and
+	// the JSON result.
+	scanPath := path
+	displayPath := absPath
+	var filesystem fs.Filesystem = fs.NewBasicFS()
+	if *archivePtr != "" {
+		if watchFlag.mode != "" {
+			fmt.Fprintln(os.Stderr, "Error: --watch cannot be combined with --archive (an archive isn't live)")
+			os.Exit(1)
+		}
+		archiveFS, err := openArchiveFS(*archivePtr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening archive: %v\n", err)
+			os.Exit(1)
+		}
+		filesystem = archiveFS
+		scanPath = "/"
+		absPath = "/"
+		displayPath = *archivePtr
+	}
+
+	progress.Printf("Scanning: %s\n", displayPath)
+	progress.Printf("Excluding: %s\n\n", strings.Join(excludePatterns, ", "))
+
+	monitor, stateFile := newMonitorWithBackend(scanPath, excludePatterns, hashMode, filesystem, *backendPtr)
 
-	monitor := NewFileMonitor(path, excludePatterns)
-	stateFile := monitor.GetStateFilePath()
+	if format == FormatNDJSON {
+		emitEvent(Event{Event: "scan_start", Path: displayPath})
+	}
 
-	fmt.Println("Scanning filesystem...")
+	progress.Println("Scanning filesystem...")
 	start := time.Now()
 	if err := monitor.CollectFiles(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error collecting files: %v\n", err)
@@ -446,87 +1074,137 @@ func main() {
 
 	summaryHash := monitor.CalculateSummaryHash()
 
-	fmt.Printf("Files scanned: %d (%.3fs)\n", len(monitor.files), scanTime.Seconds())
-	fmt.Printf("Summary hash: %s\n", summaryHash)
-	fmt.Printf("State file: %s\n", stateFile)
+	progress.Printf("Files scanned: %d (%.3fs)\n", len(monitor.files), scanTime.Seconds())
+	progress.Printf("Summary hash: %s\n", summaryHash)
+	progress.Printf("State file: %s\n", stateFile)
 
 	// Load previous state
 	previousState, err := monitor.LoadState(stateFile)
 
 	if err != nil || previousState == nil {
-		fmt.Println("\nNo previous state found - this is the first run")
+		progress.Println("\nNo previous state found - this is the first run")
+	} else if previousState.RootPath != absPath {
+		progress.Printf("\nWarning: State file path mismatch!\n")
+		progress.Printf("  Expected: %s\n", absPath)
+		progress.Printf("  Found: %s\n", previousState.RootPath)
+		previousState = nil
+	}
+
+	var changes Comparison
+	hasChanges := false
+	if previousState != nil && previousState.SummaryHash != summaryHash {
+		changes = monitor.CompareStates(stateFile, previousState)
+		hasChanges = true
+	}
+
+	switch format {
+	case FormatJSON:
+		printJSONResult(displayPath, stateFile, scanTime, summaryHash, previousState, changes, hasChanges)
+	case FormatNDJSON:
+		emitNDJSONChanges(changes, hasChanges)
+		emitEvent(Event{Event: "scan_complete", Stats: map[string]interface{}{
+			"files_scanned": len(monitor.files),
+			"scan_seconds":  scanTime.Seconds(),
+			"summary_hash":  summaryHash,
+		}})
+	default:
+		printTextResult(progress, previousState, summaryHash, changes, hasChanges, verbose)
+	}
+
+	// Save current state
+	if err := monitor.SaveState(stateFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving state: %v\n", err)
+		os.Exit(1)
+	}
+
+	progress.Printf("\nState saved to: %s\n", stateFile)
+
+	if watchFlag.mode == "" {
+		return
+	}
+
+	progress.Printf("\nWatching for changes (%s mode, checkpoint every %s)...\n", watchFlag.mode, watchCheckpointPtr.String())
+
+	var watchErr error
+	if watchFlag.mode == "poll" {
+		watchErr = runPollWatch(monitor, stateFile, format, *watchDebouncePtr, *watchCheckpointPtr)
 	} else {
-		// Verify path matches
-		if previousState.RootPath != absPath {
-			fmt.Printf("\nWarning: State file path mismatch!\n")
-			fmt.Printf("  Expected: %s\n", absPath)
-			fmt.Printf("  Found: %s\n", previousState.RootPath)
-			previousState = nil
-		}
+		watchErr = runFSNotifyWatch(monitor, stateFile, format, *watchDebouncePtr, *watchCheckpointPtr)
+	}
+	if watchErr != nil {
+		fmt.Fprintf(os.Stderr, "Error in watch mode: %v\n", watchErr)
+		os.Exit(1)
 	}
+}
 
-	if previousState != nil {
-		prevHash := previousState.SummaryHash
-		prevTime := previousState.Timestamp
+// printTextResult renders the grouped, human-formatted change summary -
+// the tool's original output, unchanged from before --format existed.
+func printTextResult(progress *progressPrinter, previousState *State, summaryHash string, changes Comparison, hasChanges bool, verbose bool) {
+	if previousState == nil {
+		return
+	}
 
-		fmt.Println("\n" + strings.Repeat("=", 60))
-		fmt.Println("CHANGES SINCE LAST RUN")
-		fmt.Println(strings.Repeat("=", 60))
-		fmt.Printf("Previous scan: %s\n", prevTime)
-		fmt.Printf("Previous hash: %s\n", prevHash)
-		fmt.Printf("Current hash:  %s\n", summaryHash)
+	progress.Println("\n" + strings.Repeat("=", 60))
+	progress.Println("CHANGES SINCE LAST RUN")
+	progress.Println(strings.Repeat("=", 60))
+	progress.Printf("Previous scan: %s\n", previousState.Timestamp)
+	progress.Printf("Previous hash: %s\n", previousState.SummaryHash)
+	progress.Printf("Current hash:  %s\n", summaryHash)
 
-		if prevHash == summaryHash {
-			fmt.Println("\n✓ No changes detected (hash match)")
-		} else {
-			// Detailed comparison
-			changes := monitor.CompareStates(previousState)
+	if !hasChanges {
+		progress.Println("\n✓ No changes detected (hash match)")
+		return
+	}
 
-			totalChanges := len(changes["added"]) + len(changes["removed"]) + len(changes["modified"])
-			fmt.Printf("\nTotal changes: %d\n", totalChanges)
+	totalChanges := len(changes.Added) + len(changes.Removed) + len(changes.Modified) + len(changes.Renamed)
+	progress.Printf("\nTotal changes: %d\n", totalChanges)
 
-			if !verbose && totalChanges > 0 {
-				fmt.Println("(Limited to 20 directory groups. Use -v/--verbose to see all)\n")
-			}
+	if !verbose && totalChanges > 0 {
+		progress.Println("(Limited to 20 directory groups. Use -v/--verbose to see all)")
+	}
 
-			maxDisplay := 999999
-			if !verbose {
-				maxDisplay = 20
-			}
+	maxDisplay := 999999
+	if !verbose {
+		maxDisplay = 20
+	}
 
-			if len(changes["added"]) > 0 {
-				fmt.Printf("\n[+] Added files (%d):\n", len(changes["added"]))
-				for _, line := range formatGroupedChanges(changes["added"], "+", maxDisplay, verbose) {
-					fmt.Println(line)
-				}
-			}
+	if len(changes.Added) > 0 {
+		progress.Printf("\n[+] Added files (%d):\n", len(changes.Added))
+		for _, line := range formatGroupedChanges(changes.Added, "+", maxDisplay, verbose) {
+			progress.Println(line)
+		}
+	}
 
-			if len(changes["removed"]) > 0 {
-				fmt.Printf("\n[-] Removed files (%d):\n", len(changes["removed"]))
-				for _, line := range formatGroupedChanges(changes["removed"], "-", maxDisplay, verbose) {
-					fmt.Println(line)
-				}
-			}
+	if len(changes.Removed) > 0 {
+		progress.Printf("\n[-] Removed files (%d):\n", len(changes.Removed))
+		for _, line := range formatGroupedChanges(changes.Removed, "-", maxDisplay, verbose) {
+			progress.Println(line)
+		}
+	}
 
-			if len(changes["modified"]) > 0 {
-				fmt.Printf("\n[*] Modified files (%d):\n", len(changes["modified"]))
-				for _, line := range formatGroupedChanges(changes["modified"], "*", maxDisplay, verbose) {
-					fmt.Println(line)
+	if len(changes.Modified) > 0 {
+		progress.Printf("\n[*] Modified files (%d):\n", len(changes.Modified))
+		for _, line := range formatGroupedChanges(changes.Modified, "*", maxDisplay, verbose) {
+			progress.Println(line)
+		}
+		if verbose {
+			for _, path := range changes.Modified {
+				if ranges := changes.ChangedRanges[path]; len(ranges) > 0 {
+					progress.Printf("        %s: %d block(s) changed\n", path, len(ranges))
 				}
 			}
 		}
 	}
 
-	// Save current state
-	if err := monitor.SaveState(stateFile); err != nil {
-		fmt.Fprintf(os.Stderr, "Error saving state: %v\n", err)
-		os.Exit(1)
+	if len(changes.Renamed) > 0 {
+		progress.Printf("\n[~] Renamed files (%d):\n", len(changes.Renamed))
+		for _, r := range changes.Renamed {
+			progress.Printf("    ~ %s -> %s\n", r.From, r.To)
+		}
 	}
-
-	fmt.Printf("\nState saved to: %s\n", stateFile)
 }
 
-func runTimingBenchmark(rootPath string, excludePatterns []string) {
+func runTimingBenchmark(rootPath string, excludePatterns []string, hashMode bool) {
 	fmt.Println("\n" + strings.Repeat("=", 60))
 	fmt.Println("TIMING BENCHMARK")
 	fmt.Println(strings.Repeat("=", 60))
@@ -535,7 +1213,7 @@ func runTimingBenchmark(rootPath string, excludePatterns []string) {
 	for run := 1; run <= 2; run++ {
 		fmt.Printf("--- Run %d %s ---\n", run, map[bool]string{true: "(Cold cache)", false: "(Warm cache)"}[run == 1])
 
-		monitor := NewFileMonitor(rootPath, excludePatterns)
+		monitor := NewFileMonitor(rootPath, excludePatterns, hashMode, fs.NewBasicFS(), "msgpack")
 		stateFile := monitor.GetStateFilePath()
 
 		// File collection
@@ -565,9 +1243,9 @@ func runTimingBenchmark(rootPath string, excludePatterns []string) {
 				fmt.Printf("  Quick check:     No changes (hash match)\n")
 			} else {
 				start = time.Now()
-				changes := monitor.CompareStates(previousState)
+				changes := monitor.CompareStates(stateFile, previousState)
 				compareTime = time.Since(start)
-				totalChanges := len(changes["added"]) + len(changes["removed"]) + len(changes["modified"])
+				totalChanges := len(changes.Added) + len(changes.Removed) + len(changes.Modified) + len(changes.Renamed)
 				fmt.Printf("  Compare:         %.6fs (%d changes)\n", compareTime.Seconds(), totalChanges)
 			}
 		}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/jack-scott/misc_snippets/file_monitor/fs"
+)
+
+func TestCollectFilesTarFS(t *testing.T) {
+	// Archive mode scans with rootPath "/" (see openArchiveFS/main), so
+	// this exercises the same filepath.Rel("/", "/"+entryName) path real
+	// --archive usage hits, not just TarFS.Walk/Stat in isolation.
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range []string{"a.txt", "sub/b.txt"} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Size: 1, Mode: 0644}); err != nil {
+			t.Fatalf("WriteHeader: %v", err)
+		}
+		if _, err := tw.Write([]byte("x")); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar Close: %v", err)
+	}
+
+	tarfs, err := fs.NewTarFS(&buf)
+	if err != nil {
+		t.Fatalf("NewTarFS: %v", err)
+	}
+
+	monitor := NewFileMonitor("/", nil, false, tarfs, "msgpack")
+	if err := monitor.CollectFiles(); err != nil {
+		t.Fatalf("CollectFiles: %v", err)
+	}
+
+	if len(monitor.files) != 2 {
+		t.Fatalf("CollectFiles found %d files, want 2: %v", len(monitor.files), monitor.files)
+	}
+	if _, ok := monitor.files["a.txt"]; !ok {
+		t.Errorf("expected a.txt to be tracked, files: %v", monitor.files)
+	}
+	if _, ok := monitor.files["sub/b.txt"]; !ok {
+		t.Errorf("expected sub/b.txt to be tracked, files: %v", monitor.files)
+	}
+}
+
+func TestCollectFilesZipFS(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"a.txt", "sub/b.txt"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if _, err := w.Write([]byte("x")); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip Close: %v", err)
+	}
+
+	zipfs, err := fs.NewZipFS(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("NewZipFS: %v", err)
+	}
+
+	monitor := NewFileMonitor("/", nil, false, zipfs, "msgpack")
+	if err := monitor.CollectFiles(); err != nil {
+		t.Fatalf("CollectFiles: %v", err)
+	}
+
+	if len(monitor.files) != 2 {
+		t.Fatalf("CollectFiles found %d files, want 2: %v", len(monitor.files), monitor.files)
+	}
+	if _, ok := monitor.files["a.txt"]; !ok {
+		t.Errorf("expected a.txt to be tracked, files: %v", monitor.files)
+	}
+	if _, ok := monitor.files["sub/b.txt"]; !ok {
+		t.Errorf("expected sub/b.txt to be tracked, files: %v", monitor.files)
+	}
+}
+
+func TestCollectFilesExcludesRootLevelDotGit(t *testing.T) {
+	// .git, node_modules, etc. are the hardcoded default excludes in
+	// main(), and they're unanchored - they must be excluded whether they
+	// sit directly under the scan root or several directories deep.
+	memfs := fs.NewMemFS()
+	memfs.AddDir("/repo")
+	memfs.AddFile("/repo/main.go", []byte("package main"), 1, 0644)
+	memfs.AddDir("/repo/.git")
+	memfs.AddFile("/repo/.git/config", []byte("[core]"), 1, 0644)
+	memfs.AddDir("/repo/node_modules")
+	memfs.AddFile("/repo/node_modules/pkg.js", []byte("x"), 1, 0644)
+	memfs.AddDir("/repo/vendor/node_modules")
+	memfs.AddFile("/repo/vendor/node_modules/nested.js", []byte("x"), 1, 0644)
+
+	monitor := NewFileMonitor("/repo", []string{".git", "node_modules"}, false, memfs, "msgpack")
+	if err := monitor.CollectFiles(); err != nil {
+		t.Fatalf("CollectFiles: %v", err)
+	}
+
+	if _, ok := monitor.files["main.go"]; !ok {
+		t.Errorf("expected main.go to be tracked, files: %v", monitor.files)
+	}
+	if _, ok := monitor.files[".git/config"]; ok {
+		t.Errorf(".git/config should be excluded when .git sits directly under the scan root")
+	}
+	if _, ok := monitor.files["node_modules/pkg.js"]; ok {
+		t.Errorf("node_modules/pkg.js should be excluded when node_modules sits directly under the scan root")
+	}
+	if _, ok := monitor.files["vendor/node_modules/nested.js"]; ok {
+		t.Errorf("vendor/node_modules/nested.js should still be excluded when node_modules is nested")
+	}
+}
+
+func TestMatchRenamesDuplicateHash(t *testing.T) {
+	// Two removed files share a hash (e.g. identical empty files), and two
+	// added files match that hash. Each removed path must be consumed by
+	// at most one rename, and the leftover added/removed paths must not
+	// silently disappear.
+	removedHashes := map[string]string{
+		"old/a.txt": "deadbeef",
+		"old/b.txt": "deadbeef",
+		"old/c.txt": "",
+	}
+	hashes := map[string]string{
+		"new/a.txt": "deadbeef",
+		"new/b.txt": "deadbeef",
+		"new/other": "",
+	}
+
+	renamed, remainingAdded, remainingRemoved := matchRenames(
+		removedHashes,
+		[]string{"new/a.txt", "new/b.txt", "new/other"},
+		[]string{"old/a.txt", "old/b.txt", "old/c.txt"},
+		func(path string) string { return hashes[path] },
+	)
+
+	if len(renamed) != 2 {
+		t.Fatalf("expected 2 renames, got %d: %+v", len(renamed), renamed)
+	}
+
+	froms := []string{renamed[0].From, renamed[1].From}
+	sort.Strings(froms)
+	if !reflect.DeepEqual(froms, []string{"old/a.txt", "old/b.txt"}) {
+		t.Errorf("expected each removed path consumed once, got froms %v", froms)
+	}
+
+	if !reflect.DeepEqual(remainingAdded, []string{"new/other"}) {
+		t.Errorf("remainingAdded = %v, want [new/other]", remainingAdded)
+	}
+	if !reflect.DeepEqual(remainingRemoved, []string{"old/c.txt"}) {
+		t.Errorf("remainingRemoved = %v, want [old/c.txt]", remainingRemoved)
+	}
+}
+
+func TestMatcherForWatchDirHonorsNestedIgnore(t *testing.T) {
+	// The scan root excludes *.log, but sub/ has its own .monitorignore
+	// excluding *.tmp. matcherForWatchDir must merge both when resolving
+	// the matcher for sub/, while a sibling directory unaffected by sub's
+	// .monitorignore still only excludes *.log.
+	memfs := fs.NewMemFS()
+	memfs.AddDir("/repo")
+	memfs.AddFile("/repo/.monitorignore", []byte("*.log\n"), 1, 0644)
+	memfs.AddDir("/repo/sub")
+	memfs.AddFile("/repo/sub/.monitorignore", []byte("*.tmp\n"), 1, 0644)
+	memfs.AddDir("/repo/other")
+
+	monitor := NewFileMonitor("/repo", nil, false, memfs, "msgpack")
+
+	subMatcher, err := monitor.matcherForWatchDir("/repo/sub")
+	if err != nil {
+		t.Fatalf("matcherForWatchDir(sub): %v", err)
+	}
+	if excluded, _ := subMatcher.Match("sub/build.tmp"); !excluded {
+		t.Errorf("sub/build.tmp should be excluded by sub's own .monitorignore")
+	}
+	if excluded, _ := subMatcher.Match("sub/debug.log"); !excluded {
+		t.Errorf("sub/debug.log should still be excluded by the root's .monitorignore")
+	}
+
+	otherMatcher, err := monitor.matcherForWatchDir("/repo/other")
+	if err != nil {
+		t.Fatalf("matcherForWatchDir(other): %v", err)
+	}
+	if excluded, _ := otherMatcher.Match("other/build.tmp"); excluded {
+		t.Errorf("other/build.tmp should not be excluded by sub's .monitorignore")
+	}
+}
+
+func TestMatchRenamesNoHash(t *testing.T) {
+	renamed, remainingAdded, remainingRemoved := matchRenames(
+		map[string]string{"old/a.txt": ""},
+		[]string{"new/a.txt"},
+		[]string{"old/a.txt"},
+		func(path string) string { return "" },
+	)
+
+	if len(renamed) != 0 {
+		t.Fatalf("expected no renames without hashes, got %+v", renamed)
+	}
+	if !reflect.DeepEqual(remainingAdded, []string{"new/a.txt"}) {
+		t.Errorf("remainingAdded = %v, want [new/a.txt]", remainingAdded)
+	}
+	if !reflect.DeepEqual(remainingRemoved, []string{"old/a.txt"}) {
+		t.Errorf("remainingRemoved = %v, want [old/a.txt]", remainingRemoved)
+	}
+}
@@ -0,0 +1,72 @@
+package fs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// BasicFS implements Filesystem against the real, local disk. This is the
+// behavior FileMonitor had before the Filesystem interface existed.
+type BasicFS struct{}
+
+// NewBasicFS creates a Filesystem backed by the local disk.
+func NewBasicFS() *BasicFS {
+	return &BasicFS{}
+}
+
+func toFileInfo(info os.FileInfo) FileInfo {
+	fi := FileInfo{
+		Name:  info.Name(),
+		Size:  info.Size(),
+		Mode:  uint32(info.Mode()),
+		Mtime: float64(info.ModTime().UnixNano()) / 1e9,
+		IsDir: info.IsDir(),
+	}
+
+	addStatDetails(&fi, info)
+
+	return fi
+}
+
+func (b *BasicFS) Walk(root string, fn WalkFunc) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fn(path, FileInfo{}, err)
+		}
+
+		werr := fn(path, toFileInfo(info), nil)
+		if werr == SkipDir {
+			return filepath.SkipDir
+		}
+		return werr
+	})
+}
+
+func (b *BasicFS) Lstat(path string) (FileInfo, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return toFileInfo(info), nil
+}
+
+func (b *BasicFS) Stat(path string) (FileInfo, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return toFileInfo(info), nil
+}
+
+func (b *BasicFS) Open(path string) (io.ReadCloser, error) {
+	return os.Open(path)
+}
+
+func (b *BasicFS) Create(path string) (io.WriteCloser, error) {
+	return os.Create(path)
+}
+
+func (b *BasicFS) MkdirAll(path string, perm uint32) error {
+	return os.MkdirAll(path, os.FileMode(perm))
+}
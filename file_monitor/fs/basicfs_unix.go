@@ -0,0 +1,24 @@
+//go:build !windows
+
+package fs
+
+import (
+	"os"
+	"syscall"
+)
+
+// addStatDetails fills in the fields toFileInfo can't get from os.FileInfo
+// alone - the raw stat mode bits (including device file types) and the
+// device number - by type-asserting the platform-specific Sys() value.
+// This lives behind a build tag because syscall.Stat_t doesn't exist on
+// Windows; BasicFS still builds there, just without these extras.
+func addStatDetails(fi *FileInfo, info os.FileInfo) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+
+	fi.Mode = stat.Mode
+	fi.Mtime = float64(stat.Mtim.Sec) + float64(stat.Mtim.Nsec)/1e9
+	fi.Rdev = stat.Rdev
+}
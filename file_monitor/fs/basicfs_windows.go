@@ -0,0 +1,11 @@
+//go:build windows
+
+package fs
+
+import "os"
+
+// addStatDetails is a no-op on Windows: there's no equivalent of
+// syscall.Stat_t exposing raw mode bits or a device number, so BasicFS
+// falls back to the os.FileMode/ModTime-derived Mode and Mtime toFileInfo
+// already filled in.
+func addStatDetails(fi *FileInfo, info os.FileInfo) {}
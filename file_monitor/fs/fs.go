@@ -0,0 +1,44 @@
+// Package fs abstracts the filesystem FileMonitor scans behind a small
+// interface, so the real implementation (BasicFS) can be swapped for an
+// in-memory one in tests or a read-only view over an archive.
+package fs
+
+import (
+	"errors"
+	"io"
+)
+
+// FileInfo is a minimal, cross-platform stat result. Unlike os.FileInfo,
+// Mtime and Rdev are plain fields rather than requiring a Sys() type
+// assertion, so callers behave the same against every implementation.
+//
+// Mode holds raw stat-style mode bits (file type + permission) from BasicFS
+// on Unix. BasicFS on Windows and ZipFS store os.FileMode bits, TarFS stores
+// the tar header's mode, and MemFS the mode given to AddFile.
+type FileInfo struct {
+	Name  string
+	Size  int64
+	Mode  uint32 // mode bits; the encoding depends on the backend (see above)
+	Mtime float64
+	Rdev  uint64
+	IsDir bool
+}
+
+// WalkFunc is called for each file or directory visited by Walk, mirroring
+// filepath.WalkFunc.
+type WalkFunc func(path string, info FileInfo, err error) error
+
+// SkipDir is returned by a WalkFunc to skip the directory being visited.
+var SkipDir = errors.New("fs: skip this directory")
+
+// Filesystem is the set of operations FileMonitor needs. Implementations
+// back it with the real disk (BasicFS), memory (MemFS), or a read-only
+// archive (TarFS, ZipFS).
+type Filesystem interface {
+	Walk(root string, fn WalkFunc) error
+	Lstat(path string) (FileInfo, error)
+	Stat(path string) (FileInfo, error)
+	Open(path string) (io.ReadCloser, error)
+	Create(path string) (io.WriteCloser, error)
+	MkdirAll(path string, perm uint32) error
+}
@@ -0,0 +1,157 @@
+package fs
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type memEntry struct {
+	info FileInfo
+	data []byte
+}
+
+// MemFS is an in-memory Filesystem, primarily for unit tests that need to
+// exercise FileMonitor without touching the real disk.
+type MemFS struct {
+	mu      sync.Mutex
+	entries map[string]*memEntry
+}
+
+// NewMemFS creates an empty in-memory filesystem. Populate it with AddFile
+// and AddDir before use.
+func NewMemFS() *MemFS {
+	return &MemFS{entries: make(map[string]*memEntry)}
+}
+
+func memClean(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// AddFile inserts or replaces a file at p.
+func (m *MemFS) AddFile(p string, data []byte, mtime float64, mode uint32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p = memClean(p)
+	m.entries[p] = &memEntry{
+		info: FileInfo{Name: path.Base(p), Size: int64(len(data)), Mode: mode, Mtime: mtime},
+		data: data,
+	}
+}
+
+// AddDir inserts a directory marker at p.
+func (m *MemFS) AddDir(p string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p = memClean(p)
+	m.entries[p] = &memEntry{info: FileInfo{Name: path.Base(p), IsDir: true}}
+}
+
+func (m *MemFS) Walk(root string, fn WalkFunc) error {
+	m.mu.Lock()
+	paths := make([]string, 0, len(m.entries))
+	for p := range m.entries {
+		paths = append(paths, p)
+	}
+	m.mu.Unlock()
+	sort.Strings(paths)
+
+	root = memClean(root)
+	var skipped []string
+
+	for _, p := range paths {
+		if root != "." && root != "" && p != root && !strings.HasPrefix(p, root+"/") {
+			continue
+		}
+
+		skip := false
+		for _, s := range skipped {
+			if p == s || strings.HasPrefix(p, s+"/") {
+				skip = true
+				break
+			}
+		}
+		if skip {
+			continue
+		}
+
+		m.mu.Lock()
+		entry, ok := m.entries[p]
+		m.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		// Callers (FileMonitor) always pass an absolute root, computed via
+		// filepath.Abs, and expect Walk to hand back paths in that same
+		// absolute form - matching os.FileInfo-based Walk on the real
+		// disk. Re-add the leading "/" that memClean strips for storage.
+		err := fn("/"+p, entry.info, nil)
+		if err == SkipDir {
+			if entry.info.IsDir {
+				skipped = append(skipped, p)
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *MemFS) Lstat(p string) (FileInfo, error) {
+	return m.Stat(p)
+}
+
+func (m *MemFS) Stat(p string) (FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[memClean(p)]
+	if !ok {
+		return FileInfo{}, os.ErrNotExist
+	}
+	return entry.info, nil
+}
+
+func (m *MemFS) Open(p string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	entry, ok := m.entries[memClean(p)]
+	m.mu.Unlock()
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(entry.data)), nil
+}
+
+func (m *MemFS) Create(p string) (io.WriteCloser, error) {
+	return &memWriter{fs: m, path: memClean(p)}, nil
+}
+
+func (m *MemFS) MkdirAll(p string, perm uint32) error {
+	m.AddDir(p)
+	return nil
+}
+
+type memWriter struct {
+	fs   *MemFS
+	path string
+	buf  bytes.Buffer
+}
+
+func (w *memWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *memWriter) Close() error {
+	w.fs.AddFile(w.path, w.buf.Bytes(), 0, 0644)
+	return nil
+}
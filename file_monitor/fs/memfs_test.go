@@ -0,0 +1,102 @@
+package fs
+
+import (
+	"io"
+	"testing"
+)
+
+func TestMemFSWalkReturnsAbsolutePaths(t *testing.T) {
+	// FileMonitor always passes an absolute root (via filepath.Abs) and
+	// expects Walk to hand paths back in that same absolute form, the way
+	// filepath.Walk does on the real disk.
+	m := NewMemFS()
+	m.AddDir("/repo")
+	m.AddFile("/repo/main.go", []byte("package main"), 1, 0644)
+	m.AddDir("/repo/sub")
+	m.AddFile("/repo/sub/a.txt", []byte("a"), 1, 0644)
+
+	var got []string
+	err := m.Walk("/repo", func(path string, info FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		got = append(got, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+
+	want := map[string]bool{"/repo": true, "/repo/main.go": true, "/repo/sub": true, "/repo/sub/a.txt": true}
+	if len(got) != len(want) {
+		t.Fatalf("Walk visited %v, want keys of %v", got, want)
+	}
+	for _, p := range got {
+		if !want[p] {
+			t.Errorf("unexpected path %q from Walk, want a leading slash to match the absolute root", p)
+		}
+	}
+}
+
+func TestMemFSWalkSkipDir(t *testing.T) {
+	m := NewMemFS()
+	m.AddDir("/repo")
+	m.AddDir("/repo/.git")
+	m.AddFile("/repo/.git/config", []byte("x"), 1, 0644)
+	m.AddFile("/repo/main.go", []byte("package main"), 1, 0644)
+
+	var got []string
+	err := m.Walk("/repo", func(path string, info FileInfo, err error) error {
+		if info.IsDir && path == "/repo/.git" {
+			return SkipDir
+		}
+		got = append(got, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+
+	for _, p := range got {
+		if p == "/repo/.git/config" {
+			t.Errorf("expected /repo/.git/config to be skipped, got %v", got)
+		}
+	}
+}
+
+func TestMemFSOpenAndCreate(t *testing.T) {
+	m := NewMemFS()
+	m.AddFile("/f.txt", []byte("hello"), 1, 0644)
+
+	r, err := m.Open("/f.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("Open contents = %q, want %q", data, "hello")
+	}
+
+	w, err := m.Create("/new.txt")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := w.Write([]byte("written")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	r2, err := m.Open("/new.txt")
+	if err != nil {
+		t.Fatalf("Open after Create: %v", err)
+	}
+	data2, _ := io.ReadAll(r2)
+	if string(data2) != "written" {
+		t.Errorf("Create+Open contents = %q, want %q", data2, "written")
+	}
+}
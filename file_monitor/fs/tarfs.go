@@ -0,0 +1,133 @@
+package fs
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+type tarEntry struct {
+	info FileInfo
+	data []byte
+}
+
+// TarFS presents the entries of a tar archive as a read-only Filesystem,
+// so a release tarball or container layer can be scanned without ever
+// extracting it to disk.
+type TarFS struct {
+	entries map[string]*tarEntry
+	order   []string
+}
+
+// NewTarFS reads every entry of r into memory up front, since tar archives
+// are not generally seekable, so Walk/Open can be served repeatedly from
+// a single pass over the stream.
+func NewTarFS(r io.Reader) (*TarFS, error) {
+	tr := tar.NewReader(r)
+	t := &TarFS{entries: make(map[string]*tarEntry)}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := tarClean(hdr.Name)
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+
+		t.entries[name] = &tarEntry{
+			info: FileInfo{
+				Name:  path.Base(name),
+				Size:  hdr.Size,
+				Mode:  uint32(hdr.Mode),
+				Mtime: float64(hdr.ModTime.UnixNano()) / 1e9,
+				IsDir: hdr.Typeflag == tar.TypeDir,
+			},
+			data: data,
+		}
+		t.order = append(t.order, name)
+	}
+
+	sort.Strings(t.order)
+	return t, nil
+}
+
+func tarClean(name string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(path.Clean("/"+name), "/"), "/")
+}
+
+func (t *TarFS) Walk(root string, fn WalkFunc) error {
+	root = tarClean(root)
+	var skipped []string
+
+	for _, name := range t.order {
+		if root != "." && root != "" && name != root && !strings.HasPrefix(name, root+"/") {
+			continue
+		}
+
+		skip := false
+		for _, s := range skipped {
+			if name == s || strings.HasPrefix(name, s+"/") {
+				skip = true
+				break
+			}
+		}
+		if skip {
+			continue
+		}
+
+		entry := t.entries[name]
+		err := fn("/"+name, entry.info, nil)
+		if err == SkipDir {
+			if entry.info.IsDir {
+				skipped = append(skipped, name)
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *TarFS) Lstat(p string) (FileInfo, error) {
+	return t.Stat(p)
+}
+
+func (t *TarFS) Stat(p string) (FileInfo, error) {
+	entry, ok := t.entries[tarClean(p)]
+	if !ok {
+		return FileInfo{}, os.ErrNotExist
+	}
+	return entry.info, nil
+}
+
+func (t *TarFS) Open(p string) (io.ReadCloser, error) {
+	entry, ok := t.entries[tarClean(p)]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(entry.data)), nil
+}
+
+func (t *TarFS) Create(p string) (io.WriteCloser, error) {
+	return nil, fmt.Errorf("tarfs: read-only filesystem")
+}
+
+func (t *TarFS) MkdirAll(p string, perm uint32) error {
+	return fmt.Errorf("tarfs: read-only filesystem")
+}
@@ -0,0 +1,123 @@
+package fs
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+type zipEntry struct {
+	info FileInfo
+	file *zip.File
+}
+
+// ZipFS presents the entries of a zip archive as a read-only Filesystem,
+// so a release archive or packaged app image can be scanned without
+// extraction. Unlike TarFS it opens entries lazily, since zip's central
+// directory already gives random access.
+type ZipFS struct {
+	entries map[string]*zipEntry
+	order   []string
+}
+
+// NewZipFS opens the zip archive in r, which must be sized so the central
+// directory at the end can be located.
+func NewZipFS(r io.ReaderAt, size int64) (*ZipFS, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	z := &ZipFS{entries: make(map[string]*zipEntry)}
+	for _, f := range zr.File {
+		name := zipClean(f.Name)
+		z.entries[name] = &zipEntry{
+			info: FileInfo{
+				Name:  path.Base(name),
+				Size:  int64(f.UncompressedSize64),
+				Mode:  uint32(f.Mode()),
+				Mtime: float64(f.Modified.UnixNano()) / 1e9,
+				IsDir: f.FileInfo().IsDir(),
+			},
+			file: f,
+		}
+		z.order = append(z.order, name)
+	}
+	sort.Strings(z.order)
+
+	return z, nil
+}
+
+// zipClean normalizes a path the way TarFS's tarClean does, so lookups and
+// Walk's root comparisons are insensitive to a leading "/".
+func zipClean(name string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(path.Clean("/"+name), "/"), "/")
+}
+
+func (z *ZipFS) Walk(root string, fn WalkFunc) error {
+	root = zipClean(root)
+	var skipped []string
+
+	for _, name := range z.order {
+		if root != "." && root != "" && name != root && !strings.HasPrefix(name, root+"/") {
+			continue
+		}
+
+		skip := false
+		for _, s := range skipped {
+			if name == s || strings.HasPrefix(name, s+"/") {
+				skip = true
+				break
+			}
+		}
+		if skip {
+			continue
+		}
+
+		entry := z.entries[name]
+		err := fn("/"+name, entry.info, nil)
+		if err == SkipDir {
+			if entry.info.IsDir {
+				skipped = append(skipped, name)
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (z *ZipFS) Lstat(p string) (FileInfo, error) {
+	return z.Stat(p)
+}
+
+func (z *ZipFS) Stat(p string) (FileInfo, error) {
+	entry, ok := z.entries[zipClean(p)]
+	if !ok {
+		return FileInfo{}, os.ErrNotExist
+	}
+	return entry.info, nil
+}
+
+func (z *ZipFS) Open(p string) (io.ReadCloser, error) {
+	entry, ok := z.entries[zipClean(p)]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return entry.file.Open()
+}
+
+func (z *ZipFS) Create(p string) (io.WriteCloser, error) {
+	return nil, fmt.Errorf("zipfs: read-only filesystem")
+}
+
+func (z *ZipFS) MkdirAll(p string, perm uint32) error {
+	return fmt.Errorf("zipfs: read-only filesystem")
+}
@@ -0,0 +1,165 @@
+// Package ignore implements a gitignore-style pattern matcher for
+// .monitorignore files: glob syntax, "!" negation, an "(?i)" case-folding
+// prefix, and an "(?d)" "deletable" marker (borrowed from restic, meaning
+// "safe to delete this match without asking").
+package ignore
+
+import (
+	"crypto/md5"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gobwas/glob"
+)
+
+// Pattern is one compiled line of a .monitorignore file.
+type Pattern struct {
+	raw       string
+	negate    bool
+	caseFold  bool
+	deletable bool
+	g         glob.Glob
+}
+
+// Matcher evaluates a path against an ordered list of patterns, gitignore
+// style: patterns are tried in declared order and the last one that
+// matches wins, so a later "!" pattern can re-include something an
+// earlier pattern excluded.
+type Matcher struct {
+	patterns  []Pattern
+	negations bool // true if any pattern in the list negates
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = map[string]*Matcher{}
+)
+
+// Compile parses gitignore-style pattern lines into a Matcher. Identical
+// pattern sets (by content, via an md5 cache key) return the same
+// compiled Matcher, so re-scanning an unchanged ignore file skips
+// recompilation.
+func Compile(lines []string) (*Matcher, error) {
+	key := cacheKey(lines)
+
+	cacheMu.Lock()
+	if m, ok := cache[key]; ok {
+		cacheMu.Unlock()
+		return m, nil
+	}
+	cacheMu.Unlock()
+
+	m, err := compile(lines)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheMu.Lock()
+	cache[key] = m
+	cacheMu.Unlock()
+
+	return m, nil
+}
+
+func cacheKey(lines []string) string {
+	h := md5.New()
+	for _, line := range lines {
+		fmt.Fprintf(h, "%s\n", line)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func compile(lines []string) (*Matcher, error) {
+	m := &Matcher{}
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pat := Pattern{raw: line}
+
+		for {
+			switch {
+			case strings.HasPrefix(line, "(?i)"):
+				pat.caseFold = true
+				line = line[len("(?i)"):]
+			case strings.HasPrefix(line, "(?d)"):
+				pat.deletable = true
+				line = line[len("(?d)"):]
+			default:
+				goto prefixesDone
+			}
+		}
+	prefixesDone:
+
+		if strings.HasPrefix(line, "!") {
+			pat.negate = true
+			line = line[1:]
+		}
+
+		compiled := line
+		if pat.caseFold {
+			compiled = strings.ToLower(compiled)
+		}
+
+		g, err := glob.Compile(compiled, '/')
+		if err != nil {
+			return nil, fmt.Errorf("ignore: invalid pattern %q: %w", pat.raw, err)
+		}
+		pat.g = g
+
+		if pat.negate {
+			m.negations = true
+		}
+
+		m.patterns = append(m.patterns, pat)
+	}
+
+	return m, nil
+}
+
+// Match reports whether path is excluded, and whether the matching
+// pattern (if any) was marked "(?d)" deletable.
+func (m *Matcher) Match(path string) (excluded bool, deletable bool) {
+	for _, pat := range m.patterns {
+		candidate := path
+		if pat.caseFold {
+			candidate = strings.ToLower(candidate)
+		}
+
+		if pat.g.Match(candidate) {
+			excluded = !pat.negate
+			deletable = pat.deletable
+		}
+	}
+
+	return excluded, deletable
+}
+
+// CouldReinclude reports whether any negated pattern exists in the
+// matcher. It's used to decide whether descent into an excluded
+// directory can be skipped outright (filepath.SkipDir) or must continue
+// because something below it might be re-included by a "!" pattern.
+func (m *Matcher) CouldReinclude() bool {
+	return m.negations
+}
+
+// Merge combines the parent matcher's patterns with an additional set of
+// lines (e.g. a nested directory's own .monitorignore), preserving
+// declared order so the more specific, later patterns still win ties.
+func Merge(parent *Matcher, lines []string) (*Matcher, error) {
+	if len(lines) == 0 {
+		return parent, nil
+	}
+
+	combined := make([]string, 0, len(parent.patterns)+len(lines))
+	for _, pat := range parent.patterns {
+		combined = append(combined, pat.raw)
+	}
+	combined = append(combined, lines...)
+
+	return Compile(combined)
+}
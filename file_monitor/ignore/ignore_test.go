@@ -0,0 +1,58 @@
+package ignore
+
+import "testing"
+
+func TestMatchUnanchoredAtRoot(t *testing.T) {
+	// "{,**/}.git" is what FileMonitor's gitignoreGlob translates a bare
+	// unanchored line into; it must match the entry both at the scan
+	// root and several directories deep.
+	m, err := Compile([]string{"{,**/}.git", "{,**/}node_modules"})
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	cases := []struct {
+		path     string
+		excluded bool
+	}{
+		{".git", true},
+		{".git/config", false}, // only the directory itself is matched; with no negated pattern, CollectFiles skips the subtree via SkipDir
+		{"vendor/node_modules", true},
+		{"src/main.go", false},
+	}
+
+	for _, c := range cases {
+		excluded, _ := m.Match(c.path)
+		if excluded != c.excluded {
+			t.Errorf("Match(%q) = %v, want %v", c.path, excluded, c.excluded)
+		}
+	}
+}
+
+func TestMatchNegation(t *testing.T) {
+	m, err := Compile([]string{"{,**/}*.log", "!keep.log"})
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	if excluded, _ := m.Match("debug.log"); !excluded {
+		t.Errorf("debug.log should be excluded")
+	}
+	if excluded, _ := m.Match("keep.log"); excluded {
+		t.Errorf("keep.log should be re-included by the negated pattern")
+	}
+	if !m.CouldReinclude() {
+		t.Errorf("CouldReinclude() = false, want true with a negated pattern present")
+	}
+}
+
+func TestMatchCaseFold(t *testing.T) {
+	m, err := Compile([]string{"(?i)readme.md"})
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	if excluded, _ := m.Match("README.MD"); !excluded {
+		t.Errorf("README.MD should match a (?i) case-folded pattern")
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Output format names accepted by --format.
+const (
+	FormatText   = "text"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+)
+
+// resolveFormat decides the effective output format. An explicit --format
+// always wins. Otherwise --no-console means ndjson. Otherwise it falls
+// back to autodetection in the style of buildkit's console fallback:
+// check whether stderr is an interactive TTY, and if it's been piped or
+// redirected instead, assume nothing is watching the decorated output and
+// downgrade to the machine-readable ndjson format.
+func resolveFormat(explicit string, noConsole bool) string {
+	if explicit != "" {
+		return explicit
+	}
+	if noConsole {
+		return FormatNDJSON
+	}
+	if !isTerminal(os.Stderr) {
+		return FormatNDJSON
+	}
+	return FormatText
+}
+
+// isTerminal reports whether f is attached to a character device, i.e. an
+// interactive terminal rather than a pipe or redirected file.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// progressPrinter writes the scan's interstitial progress lines (the
+// "Scanning: ...", "Files scanned: ..." banter). For FormatText it writes
+// to stdout as before; for the structured formats that output is reserved
+// for the scan result, so progress instead goes to stderr.
+type progressPrinter struct {
+	w io.Writer
+}
+
+func newProgressPrinter(format string) *progressPrinter {
+	if format == FormatText {
+		return &progressPrinter{w: os.Stdout}
+	}
+	return &progressPrinter{w: os.Stderr}
+}
+
+func (p *progressPrinter) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(p.w, format, args...)
+}
+
+func (p *progressPrinter) Println(args ...interface{}) {
+	fmt.Fprintln(p.w, args...)
+}
+
+// Event is one line of the NDJSON event log: scan_start, file_changed, or
+// scan_complete. Fields irrelevant to a given Event are omitted.
+type Event struct {
+	Event string                 `json:"event"`
+	Time  string                 `json:"time"`
+	Path  string                 `json:"path,omitempty"`
+	Kind  string                 `json:"kind,omitempty"`
+	From  string                 `json:"from,omitempty"`
+	Stats map[string]interface{} `json:"stats,omitempty"`
+}
+
+// emitEvent writes e as a single line of JSON to stdout, stamping its time
+// if the caller left it blank.
+func emitEvent(e Event) {
+	if e.Time == "" {
+		e.Time = time.Now().Format(time.RFC3339)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	if err := enc.Encode(e); err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding event: %v\n", err)
+	}
+}
+
+// emitNDJSONChanges streams one file_changed event per added, removed,
+// modified, or renamed path, in that order.
+func emitNDJSONChanges(changes Comparison, hasChanges bool) {
+	if !hasChanges {
+		return
+	}
+
+	for _, p := range changes.Added {
+		emitEvent(Event{Event: "file_changed", Path: p, Kind: "added"})
+	}
+	for _, p := range changes.Removed {
+		emitEvent(Event{Event: "file_changed", Path: p, Kind: "removed"})
+	}
+	for _, p := range changes.Modified {
+		emitEvent(Event{Event: "file_changed", Path: p, Kind: "modified"})
+	}
+	for _, r := range changes.Renamed {
+		emitEvent(Event{Event: "file_changed", Path: r.To, Kind: "renamed", From: r.From})
+	}
+}
+
+// ScanResult is the single end-of-run object printed for --format=json.
+type ScanResult struct {
+	RootPath    string     `json:"root_path"`
+	StateFile   string     `json:"state_file"`
+	ScanSeconds float64    `json:"scan_seconds"`
+	SummaryHash string     `json:"summary_hash"`
+	FirstRun    bool       `json:"first_run"`
+	HasChanges  bool       `json:"has_changes"`
+	Comparison  Comparison `json:"comparison"`
+}
+
+// printJSONResult marshals a ScanResult for the whole run to stdout as a
+// single JSON document, rather than streaming events like ndjson does.
+func printJSONResult(rootPath, stateFile string, scanTime time.Duration, summaryHash string, previousState *State, changes Comparison, hasChanges bool) {
+	result := ScanResult{
+		RootPath:    rootPath,
+		StateFile:   stateFile,
+		ScanSeconds: scanTime.Seconds(),
+		SummaryHash: summaryHash,
+		FirstRun:    previousState == nil,
+		HasChanges:  hasChanges,
+	}
+	if hasChanges {
+		result.Comparison = changes
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding result: %v\n", err)
+	}
+}
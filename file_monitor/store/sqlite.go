@@ -0,0 +1,311 @@
+// Package store provides an alternative to the msgpack/json state blob:
+// a SQLite-backed store that upserts individual file rows instead of
+// rewriting the entire state on every run, so it scales to trees with
+// millions of files.
+package store
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS files (
+	path TEXT PRIMARY KEY,
+	mtime REAL,
+	mode INTEGER,
+	rdev INTEGER,
+	hash BLOB,
+	updated_at INTEGER
+);
+CREATE TABLE IF NOT EXISTS runs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	root_path TEXT,
+	timestamp TEXT,
+	file_count INTEGER,
+	summary_hash TEXT
+);
+`
+
+// upsertBatchSize is how many rows go into a single transaction, balancing
+// commit overhead against how much an interrupted run can lose.
+const upsertBatchSize = 1000
+
+// Record is one file's persisted metadata.
+type Record struct {
+	Path  string
+	Mtime float64
+	Mode  uint32
+	Rdev  uint64
+	Hash  []byte
+}
+
+// RunMeta is the scan-level metadata stored alongside the file rows.
+type RunMeta struct {
+	RootPath    string
+	Timestamp   string
+	FileCount   int
+	SummaryHash string
+}
+
+// SQLiteBackend persists FileMonitor state in a SQLite database, via the
+// pure-Go modernc.org/sqlite driver so the binary stays cgo-free.
+type SQLiteBackend struct {
+	db *sql.DB
+}
+
+// Open opens (creating if necessary) the SQLite database at path and
+// ensures its schema exists.
+func Open(path string) (*SQLiteBackend, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteBackend{db: db}, nil
+}
+
+// Close closes the underlying database connection.
+func (s *SQLiteBackend) Close() error {
+	return s.db.Close()
+}
+
+// SaveState prunes rows for paths no longer present in the scan, batches
+// the current records into the files table via INSERT OR REPLACE in
+// transactions of upsertBatchSize rows, then records the scan metadata in
+// runs.
+func (s *SQLiteBackend) SaveState(meta RunMeta, records []Record) error {
+	paths := make([]string, len(records))
+	for i, r := range records {
+		paths[i] = r.Path
+	}
+	if err := s.deleteMissing(paths); err != nil {
+		return err
+	}
+
+	for start := 0; start < len(records); start += upsertBatchSize {
+		end := start + upsertBatchSize
+		if end > len(records) {
+			end = len(records)
+		}
+
+		if err := s.upsertBatch(records[start:end]); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.db.Exec(
+		`INSERT INTO runs(root_path, timestamp, file_count, summary_hash) VALUES (?, ?, ?, ?)`,
+		meta.RootPath, meta.Timestamp, meta.FileCount, meta.SummaryHash,
+	)
+	return err
+}
+
+// deleteMissing removes rows for paths not present in the current scan -
+// otherwise a file deleted from disk stays in the files table forever and
+// LoadMeta/Diff keep reporting it as "previous" on every later run, since
+// SaveState used to only ever INSERT OR REPLACE and never pruned.
+func (s *SQLiteBackend) deleteMissing(paths []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// TEMP TABLEs live for the connection, not the transaction, and
+	// database/sql can hand Begin() the same pooled connection it gave a
+	// prior SaveState call, so CREATE TEMP TABLE alone fails with "table
+	// already exists" on a second call. IF NOT EXISTS plus clearing any
+	// leftover rows handles both a reused connection and a fresh one.
+	if _, err := tx.Exec(`CREATE TEMP TABLE IF NOT EXISTS current_paths (path TEXT PRIMARY KEY)`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM current_paths`); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO current_paths(path) VALUES (?)`)
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		if _, err := stmt.Exec(p); err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+	stmt.Close()
+
+	if _, err := tx.Exec(`DELETE FROM files WHERE path NOT IN (SELECT path FROM current_paths)`); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteBackend) upsertBatch(records []Record) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO files(path, mtime, mode, rdev, hash, updated_at) VALUES (?, ?, ?, ?, ?, strftime('%s', 'now'))`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, r := range records {
+		if _, err := stmt.Exec(r.Path, r.Mtime, r.Mode, r.Rdev, r.Hash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// LoadMeta returns the most recent run's metadata. A database with no
+// runs yet returns a zero RunMeta and no error. Unlike the old LoadState,
+// this never reads the files table, so checking "did anything change
+// since last time" doesn't require loading every row into Go first -
+// Diff does the per-file comparison separately, in SQL.
+func (s *SQLiteBackend) LoadMeta() (RunMeta, error) {
+	var meta RunMeta
+	row := s.db.QueryRow(`SELECT root_path, timestamp, file_count, summary_hash FROM runs ORDER BY id DESC LIMIT 1`)
+	if err := row.Scan(&meta.RootPath, &meta.Timestamp, &meta.FileCount, &meta.SummaryHash); err != nil {
+		if err == sql.ErrNoRows {
+			return RunMeta{}, nil
+		}
+		return RunMeta{}, err
+	}
+	return meta, nil
+}
+
+// Diff compares the current scan's records against the files table
+// entirely in SQL via a temp table join, returning added, removed, and
+// modified paths without ever loading the stored table into a Go map -
+// the piece of the "scales to millions of files" goal SaveState's pruning
+// alone didn't deliver.
+func (s *SQLiteBackend) Diff(current []Record) (added, removed, modified []string, err error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer tx.Rollback()
+
+	// See deleteMissing: TEMP TABLEs outlive the transaction on a pooled
+	// connection, so a second Diff call needs IF NOT EXISTS plus a clear
+	// rather than a bare CREATE.
+	if _, err = tx.Exec(`CREATE TEMP TABLE IF NOT EXISTS current_scan (path TEXT PRIMARY KEY, mtime REAL, mode INTEGER, rdev INTEGER, hash BLOB)`); err != nil {
+		return nil, nil, nil, err
+	}
+	if _, err = tx.Exec(`DELETE FROM current_scan`); err != nil {
+		return nil, nil, nil, err
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO current_scan(path, mtime, mode, rdev, hash) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, r := range current {
+		if _, err = stmt.Exec(r.Path, r.Mtime, r.Mode, r.Rdev, r.Hash); err != nil {
+			stmt.Close()
+			return nil, nil, nil, err
+		}
+	}
+	stmt.Close()
+
+	addedRows, err := tx.Query(`
+		SELECT current_scan.path FROM current_scan
+		LEFT JOIN files ON files.path = current_scan.path
+		WHERE files.path IS NULL
+	`)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if added, err = scanPaths(addedRows); err != nil {
+		return nil, nil, nil, err
+	}
+
+	removedRows, err := tx.Query(`
+		SELECT files.path FROM files
+		LEFT JOIN current_scan ON current_scan.path = files.path
+		WHERE current_scan.path IS NULL
+	`)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if removed, err = scanPaths(removedRows); err != nil {
+		return nil, nil, nil, err
+	}
+
+	modifiedRows, err := tx.Query(`
+		SELECT current_scan.path FROM current_scan
+		JOIN files ON files.path = current_scan.path
+		WHERE files.mtime != current_scan.mtime OR files.rdev != current_scan.rdev
+	`)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if modified, err = scanPaths(modifiedRows); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return added, removed, modified, nil
+}
+
+func scanPaths(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+	var paths []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, rows.Err()
+}
+
+// HashesFor returns the stored hex-encoded hash for each of paths, for
+// callers (rename detection) that only need a handful of rows rather than
+// the whole table.
+func (s *SQLiteBackend) HashesFor(paths []string) (map[string]string, error) {
+	result := make(map[string]string, len(paths))
+	if len(paths) == 0 {
+		return result, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(paths)), ",")
+	args := make([]interface{}, len(paths))
+	for i, p := range paths {
+		args[i] = p
+	}
+
+	rows, err := s.db.Query(fmt.Sprintf(`SELECT path, hash FROM files WHERE path IN (%s)`, placeholders), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var path string
+		var hash []byte
+		if err := rows.Scan(&path, &hash); err != nil {
+			return nil, err
+		}
+		result[path] = hex.EncodeToString(hash)
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func openTestBackend(t *testing.T) *SQLiteBackend {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSaveStateDeletesMissing(t *testing.T) {
+	db := openTestBackend(t)
+
+	if err := db.SaveState(RunMeta{RootPath: "/repo"}, []Record{
+		{Path: "a.txt"},
+		{Path: "b.txt"},
+	}); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+
+	// b.txt is gone from the second scan; it must not linger in files.
+	if err := db.SaveState(RunMeta{RootPath: "/repo"}, []Record{
+		{Path: "a.txt"},
+	}); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+
+	hashes, err := db.HashesFor([]string{"a.txt", "b.txt"})
+	if err != nil {
+		t.Fatalf("HashesFor: %v", err)
+	}
+	if _, ok := hashes["b.txt"]; ok {
+		t.Errorf("b.txt should have been deleted from files, got %v", hashes)
+	}
+	if _, ok := hashes["a.txt"]; !ok {
+		t.Errorf("a.txt should still be present, got %v", hashes)
+	}
+}
+
+func TestDiffAddedRemovedModified(t *testing.T) {
+	db := openTestBackend(t)
+
+	if err := db.SaveState(RunMeta{RootPath: "/repo"}, []Record{
+		{Path: "same.txt", Mtime: 1},
+		{Path: "gone.txt", Mtime: 1},
+		{Path: "changed.txt", Mtime: 1},
+	}); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+
+	added, removed, modified, err := db.Diff([]Record{
+		{Path: "same.txt", Mtime: 1},
+		{Path: "changed.txt", Mtime: 2},
+		{Path: "new.txt", Mtime: 1},
+	})
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+
+	if len(added) != 1 || added[0] != "new.txt" {
+		t.Errorf("added = %v, want [new.txt]", added)
+	}
+	if len(removed) != 1 || removed[0] != "gone.txt" {
+		t.Errorf("removed = %v, want [gone.txt]", removed)
+	}
+	if len(modified) != 1 || modified[0] != "changed.txt" {
+		t.Errorf("modified = %v, want [changed.txt]", modified)
+	}
+}
+
+func TestLoadMetaNoRuns(t *testing.T) {
+	db := openTestBackend(t)
+
+	meta, err := db.LoadMeta()
+	if err != nil {
+		t.Fatalf("LoadMeta: %v", err)
+	}
+	if meta != (RunMeta{}) {
+		t.Errorf("LoadMeta on an empty database = %+v, want zero value", meta)
+	}
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/jack-scott/misc_snippets/file_monitor/ignore"
+)
+
+// watchModeFlag is a flag.Value that also implements the boolean flag
+// optional interface, so "--watch" alone enables fsnotify-based watching
+// and "--watch=poll" selects the fallback poller, without requiring the
+// caller to spell out "--watch=true".
+type watchModeFlag struct {
+	mode string // "", "fsnotify", or "poll"
+}
+
+func (w *watchModeFlag) String() string { return w.mode }
+
+func (w *watchModeFlag) Set(s string) error {
+	switch s {
+	case "true", "fsnotify", "":
+		w.mode = "fsnotify"
+	case "poll":
+		w.mode = "poll"
+	default:
+		return fmt.Errorf("invalid --watch value %q (want fsnotify or poll)", s)
+	}
+	return nil
+}
+
+func (w *watchModeFlag) IsBoolFlag() bool { return true }
+
+// matcherForWatchDir returns the ignore.Matcher in effect for dir, merging
+// every .monitorignore between fm.rootPath and dir. CollectFiles gets this
+// for free from the stack it builds while walking the tree top-down, but
+// watch events arrive for arbitrary directories in arbitrary order, so
+// here it's resolved on demand and cached per directory instead, falling
+// back to readIgnoreLines/ignore.Merge one path component at a time.
+func (fm *FileMonitor) matcherForWatchDir(dir string) (*ignore.Matcher, error) {
+	if fm.watchMatchers == nil {
+		fm.watchMatchers = make(map[string]*ignore.Matcher)
+	}
+	if m, ok := fm.watchMatchers[dir]; ok {
+		return m, nil
+	}
+
+	if dir == fm.rootPath {
+		matcher, err := fm.rootMatcher()
+		if err != nil {
+			return nil, err
+		}
+		fm.watchMatchers[dir] = matcher
+		return matcher, nil
+	}
+
+	parent := filepath.Dir(dir)
+	if parent == dir || !isWithinDir(fm.rootPath, dir) {
+		// Outside the watched tree; fall back to the root matcher rather
+		// than recursing forever.
+		return fm.matcherForWatchDir(fm.rootPath)
+	}
+
+	parentMatcher, err := fm.matcherForWatchDir(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := fm.readIgnoreLines(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	matcher := parentMatcher
+	if len(lines) > 0 {
+		matcher, err = ignore.Merge(parentMatcher, lines)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fm.watchMatchers[dir] = matcher
+	return matcher, nil
+}
+
+// invalidateWatchMatchers drops cached matchers for dir and everything
+// beneath it, so a .monitorignore created or edited after the watch
+// started is picked up on the next event instead of being matched
+// against a stale cache entry forever.
+func (fm *FileMonitor) invalidateWatchMatchers(dir string) {
+	for cached := range fm.watchMatchers {
+		if isWithinDir(dir, cached) {
+			delete(fm.watchMatchers, cached)
+		}
+	}
+}
+
+// addWatchesRecursive registers w for dir and every non-excluded
+// subdirectory beneath it, resolving each directory's own nested
+// .monitorignore via fm.matcherForWatchDir rather than a single matcher
+// passed in from the root. It walks the real OS filesystem rather than
+// fm.filesystem, since fsnotify only ever watches the actual disk.
+func (fm *FileMonitor) addWatchesRecursive(w *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+
+		rel, relErr := filepath.Rel(fm.rootPath, path)
+		if relErr != nil {
+			return nil
+		}
+
+		if rel != "." {
+			matcher, mErr := fm.matcherForWatchDir(filepath.Dir(path))
+			if mErr != nil {
+				return nil
+			}
+			if excluded, _ := matcher.Match(rel); excluded {
+				return filepath.SkipDir
+			}
+		}
+
+		return w.Add(path)
+	})
+}
+
+// addExistingFiles folds the files already present under a newly created
+// directory into fm.files, since fsnotify only reports the Create event
+// for the directory itself, not for anything that existed inside it
+// before the watch was registered (e.g. a directory moved in from
+// elsewhere on the same volume).
+func (fm *FileMonitor) addExistingFiles(dir string) {
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+
+		rel, relErr := filepath.Rel(fm.rootPath, path)
+		if relErr != nil {
+			return nil
+		}
+
+		matcher, mErr := fm.matcherForWatchDir(filepath.Dir(path))
+		if mErr != nil {
+			return nil
+		}
+		if excluded, _ := matcher.Match(rel); excluded {
+			return nil
+		}
+
+		stat, statErr := fm.filesystem.Lstat(path)
+		if statErr != nil {
+			return nil
+		}
+
+		fileInfo := FileInfo{Mtime: stat.Mtime, Mode: stat.Mode, Rdev: stat.Rdev}
+		if fm.hashMode {
+			if blocks, hash, err := fm.computeBlocks(path); err == nil {
+				fileInfo.Blocks = blocks
+				fileInfo.Hash = hash
+			}
+		}
+		fm.files[rel] = fileInfo
+
+		return nil
+	})
+}
+
+// applyWatchEvent updates fm.files for a single fsnotify event and
+// returns the kind of change it represents ("added", "removed", or
+// "modified"), or "" if the event is outside the tree being watched, is
+// for an excluded path, or didn't change anything we track (e.g. a
+// directory's own mtime). The matcher used is resolved for the event's own
+// directory, so a .monitorignore several levels below the scan root is
+// honored instead of only the one at fm.rootPath.
+func (fm *FileMonitor) applyWatchEvent(w *fsnotify.Watcher, event fsnotify.Event) string {
+	rel, err := filepath.Rel(fm.rootPath, event.Name)
+	if err != nil {
+		return ""
+	}
+
+	dir := filepath.Dir(event.Name)
+	if filepath.Base(event.Name) == monitorIgnoreFile {
+		fm.invalidateWatchMatchers(dir)
+	}
+
+	matcher, err := fm.matcherForWatchDir(dir)
+	if err != nil {
+		return ""
+	}
+	if excluded, _ := matcher.Match(rel); excluded {
+		return ""
+	}
+
+	if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+		if _, existed := fm.files[rel]; !existed {
+			return ""
+		}
+		delete(fm.files, rel)
+		return "removed"
+	}
+
+	stat, err := fm.filesystem.Lstat(event.Name)
+	if err != nil {
+		// Most likely the file was removed again before we could stat it.
+		return ""
+	}
+
+	if stat.IsDir {
+		if event.Op&fsnotify.Create != 0 {
+			fm.invalidateWatchMatchers(event.Name)
+			fm.addWatchesRecursive(w, event.Name)
+			fm.addExistingFiles(event.Name)
+		}
+		return ""
+	}
+
+	_, existed := fm.files[rel]
+	fileInfo := FileInfo{Mtime: stat.Mtime, Mode: stat.Mode, Rdev: stat.Rdev}
+	if fm.hashMode {
+		if blocks, hash, err := fm.computeBlocks(event.Name); err == nil {
+			fileInfo.Blocks = blocks
+			fileInfo.Hash = hash
+		}
+	}
+	fm.files[rel] = fileInfo
+
+	if existed {
+		return "modified"
+	}
+	return "added"
+}
+
+// netPendingKind folds a newly observed kind into the kind already pending
+// for path within the same debounce window, rather than letting the latest
+// event blindly overwrite it. A real file creation almost always fires
+// Create then Write in quick succession, and applyWatchEvent reports the
+// Write as "modified" since the file already exists in fm.files by then -
+// without this, every such creation would be misreported as a modification
+// and never as an addition. Returns "" when the net effect across the
+// window is no visible change at all (created then removed again).
+func netPendingKind(prev, next string) string {
+	switch {
+	case prev == "added" && next == "modified":
+		return "added"
+	case prev == "added" && next == "removed":
+		return ""
+	case prev == "removed" && next == "added":
+		return "modified"
+	default:
+		return next
+	}
+}
+
+// comparisonFromPending turns the path->kind map a debounce window
+// accumulated into the same Comparison shape CompareStates produces, so
+// both watch modes can share one rendering path.
+func comparisonFromPending(pending map[string]string) Comparison {
+	var c Comparison
+	for path, kind := range pending {
+		switch kind {
+		case "added":
+			c.Added = append(c.Added, path)
+		case "removed":
+			c.Removed = append(c.Removed, path)
+		case "modified":
+			c.Modified = append(c.Modified, path)
+		}
+	}
+	return c
+}
+
+// emitWatchComparison prints one tick's worth of changes, in ndjson or
+// the same grouped text format the initial scan's comparison uses.
+func emitWatchComparison(format string, changes Comparison) {
+	if format == FormatNDJSON {
+		emitNDJSONChanges(changes, true)
+		return
+	}
+
+	fmt.Printf("\n[watch] %s\n", time.Now().Format(time.RFC3339))
+	for _, line := range formatGroupedChanges(changes.Added, "+", 20, false) {
+		fmt.Println(line)
+	}
+	for _, line := range formatGroupedChanges(changes.Removed, "-", 20, false) {
+		fmt.Println(line)
+	}
+	for _, line := range formatGroupedChanges(changes.Modified, "*", 20, false) {
+		fmt.Println(line)
+	}
+	for _, r := range changes.Renamed {
+		fmt.Printf("    ~ %s -> %s\n", r.From, r.To)
+	}
+}
+
+// runFSNotifyWatch subscribes to filesystem events under monitor.rootPath
+// and keeps monitor.files up to date incrementally, event by event,
+// instead of re-walking the tree. Events are debounced so a burst of
+// writes (a compiler, an rsync) produces one comparison pass rather than
+// one per touched file, and the state file is checkpointed to disk on
+// checkpointInterval regardless of whether anything changed.
+func runFSNotifyWatch(monitor *FileMonitor, stateFile string, format string, debounce time.Duration, checkpointInterval time.Duration) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if err := monitor.addWatchesRecursive(watcher, monitor.rootPath); err != nil {
+		return err
+	}
+
+	pending := make(map[string]string)
+
+	debounceTimer := time.NewTimer(debounce)
+	if !debounceTimer.Stop() {
+		<-debounceTimer.C
+	}
+
+	checkpointTicker := time.NewTicker(checkpointInterval)
+	defer checkpointTicker.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			kind := monitor.applyWatchEvent(watcher, event)
+			if kind == "" {
+				continue
+			}
+
+			rel, relErr := filepath.Rel(monitor.rootPath, event.Name)
+			if relErr != nil {
+				continue
+			}
+			if existing, ok := pending[rel]; ok {
+				kind = netPendingKind(existing, kind)
+			}
+			if kind == "" {
+				delete(pending, rel)
+			} else {
+				pending[rel] = kind
+			}
+
+			if !debounceTimer.Stop() {
+				select {
+				case <-debounceTimer.C:
+				default:
+				}
+			}
+			debounceTimer.Reset(debounce)
+
+		case <-debounceTimer.C:
+			if len(pending) > 0 {
+				emitWatchComparison(format, comparisonFromPending(pending))
+				pending = make(map[string]string)
+			}
+
+		case <-checkpointTicker.C:
+			if err := monitor.SaveState(stateFile); err != nil {
+				fmt.Fprintf(os.Stderr, "Error checkpointing state: %v\n", err)
+			}
+
+		case watchErr, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Fprintf(os.Stderr, "Watch error: %v\n", watchErr)
+		}
+	}
+}
+
+// runPollWatch is the fallback for filesystems fsnotify can't watch
+// (network mounts, some FUSE backends): it re-walks the tree on a fixed
+// interval and diffs against the previous walk via the same CompareStates
+// path the one-shot scan uses, rather than tracking individual events.
+func runPollWatch(monitor *FileMonitor, stateFile string, format string, interval time.Duration, checkpointInterval time.Duration) error {
+	lastCheckpoint := time.Now()
+
+	for {
+		time.Sleep(interval)
+
+		previous := &State{RootPath: monitor.rootPath, Files: monitor.files}
+		monitor.files = make(map[string]FileInfo)
+		if err := monitor.CollectFiles(); err != nil {
+			return err
+		}
+
+		changes := monitor.CompareStates(stateFile, previous)
+		totalChanges := len(changes.Added) + len(changes.Removed) + len(changes.Modified) + len(changes.Renamed)
+		if totalChanges > 0 {
+			emitWatchComparison(format, changes)
+		}
+
+		if time.Since(lastCheckpoint) >= checkpointInterval {
+			if err := monitor.SaveState(stateFile); err != nil {
+				fmt.Fprintf(os.Stderr, "Error checkpointing state: %v\n", err)
+			}
+			lastCheckpoint = time.Now()
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestNetPendingKind(t *testing.T) {
+	// A real file creation on disk almost always fires Create then Write
+	// in quick succession; applyWatchEvent reports the Write as
+	// "modified" since the file already exists in fm.files by then, so
+	// the net kind across the debounce window must still read as
+	// "added", not overwrite it to "modified".
+	cases := []struct {
+		prev, next, want string
+	}{
+		{"added", "modified", "added"},
+		{"added", "removed", ""},
+		{"removed", "added", "modified"},
+		{"modified", "modified", "modified"},
+		{"modified", "removed", "removed"},
+	}
+
+	for _, c := range cases {
+		if got := netPendingKind(c.prev, c.next); got != c.want {
+			t.Errorf("netPendingKind(%q, %q) = %q, want %q", c.prev, c.next, got, c.want)
+		}
+	}
+}